@@ -1,23 +1,38 @@
 package aws
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/BishopFox/cloudfox/aws/graph/ingester/schema/models"
 	"github.com/BishopFox/cloudfox/aws/sdk"
 	"github.com/BishopFox/cloudfox/internal"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/codebuild/types"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/bishopfox/awsservicemap"
 	"github.com/sirupsen/logrus"
 )
 
+// defaultSecretEnvVarRegex flags PLAINTEXT environment variables whose name
+// looks like it holds a credential, so a hijacked build can't quietly read
+// them out of the project definition.
+const defaultSecretEnvVarRegex = `(?i)(token|secret|key|pass)`
+
 type CodeBuildModule struct {
 	// General configuration data
 	CodeBuildClient sdk.CodeBuildClientInterface
 	IAMClient       sdk.AWSIAMClientInterface
+	S3Client        sdk.AWSS3ClientInterface
 
 	Caller              sts.GetCallerIdentityOutput
 	AWSRegions          []string
@@ -25,6 +40,16 @@ type CodeBuildModule struct {
 	AWSTableCols        string
 	PmapperDataBasePath string
 
+	// SecretEnvVarRegex overrides the pattern used to flag suspicious
+	// PLAINTEXT environment variable names. Defaults to defaultSecretEnvVarRegex.
+	SecretEnvVarRegex string
+
+	// FetchBuildspecs opts into fetching the real buildspec from the
+	// project's S3/GitHub source when CodeBuild only stored a path to it.
+	// Off by default: it's an extra outbound call (and extra noise) per
+	// project, so it's opt-in rather than automatic.
+	FetchBuildspecs bool
+
 	Goroutines     int
 	AWSProfile     string
 	SkipAdminCheck bool
@@ -32,6 +57,7 @@ type CodeBuildModule struct {
 	pmapperMod     PmapperModule
 	pmapperError   error
 	iamSimClient   IamSimulatorModule
+	secretRegex    *regexp.Regexp
 
 	// Main module data
 	Projects       []Project
@@ -41,6 +67,18 @@ type CodeBuildModule struct {
 	modLog *logrus.Entry
 }
 
+type EnvironmentVariable struct {
+	Name     string
+	Type     string
+	Value    string
+	IsSecret bool
+}
+
+type WebhookFilterGroup struct {
+	Type    string
+	Pattern string
+}
+
 type Project struct {
 	Region     string
 	Name       string
@@ -48,6 +86,26 @@ type Project struct {
 	Role       string
 	Admin      string
 	CanPrivEsc string
+
+	SourceType     string
+	SourceLocation string
+	SourceAuthType string
+
+	WebhookEnabled      bool
+	WebhookFilterGroups []WebhookFilterGroup
+	IsPRTriggered       bool
+
+	EnvironmentVariables []EnvironmentVariable
+	HasPlaintextSecret   bool
+
+	SecondarySources []string
+	PrivilegedMode   bool
+	Buildspec        string
+
+	// IsHijackTarget is computed after the pmapper/IAM-sim pass since it
+	// depends on whether the project's service role is an admin/priv-esc
+	// path -- see PrintCodeBuildProjects.
+	IsHijackTarget bool
 }
 
 func (m *CodeBuildModule) PrintCodeBuildProjects(outputDirectory string, verbosity int) {
@@ -64,6 +122,17 @@ func (m *CodeBuildModule) PrintCodeBuildProjects(outputDirectory string, verbosi
 		m.AWSProfile = internal.BuildAWSPath(m.Caller)
 	}
 
+	secretPattern := m.SecretEnvVarRegex
+	if secretPattern == "" {
+		secretPattern = defaultSecretEnvVarRegex
+	}
+	var err error
+	m.secretRegex, err = regexp.Compile(secretPattern)
+	if err != nil {
+		m.modLog.Errorf("invalid SecretEnvVarRegex %q, falling back to default: %s", secretPattern, err)
+		m.secretRegex = regexp.MustCompile(defaultSecretEnvVarRegex)
+	}
+
 	fmt.Printf("[%s][%s] Enumerating CodeBuild projects for account %s.\n", cyan(m.output.CallingModule), cyan(m.AWSProfile), aws.ToString(m.Caller.Account))
 	m.pmapperMod, m.pmapperError = InitPmapperGraph(m.Caller, m.AWSProfile, m.Goroutines, m.PmapperDataBasePath)
 	m.iamSimClient = InitIamCommandClient(m.IAMClient, m.Caller, m.AWSProfile, m.Goroutines)
@@ -110,6 +179,16 @@ func (m *CodeBuildModule) PrintCodeBuildProjects(outputDirectory string, verbosi
 		}
 	}
 
+	// A project is a hijack target when someone who can send it a pull
+	// request can also walk away with secrets or an admin/priv-esc role:
+	// PR-triggered webhook + privileged build + (plaintext secret or a
+	// service role that's already an admin/priv-esc path).
+	for i := range m.Projects {
+		p := &m.Projects[i]
+		roleIsDangerous := p.Admin == "YES" || p.CanPrivEsc == "YES"
+		p.IsHijackTarget = p.IsPRTriggered && p.PrivilegedMode && (p.HasPlaintextSecret || roleIsDangerous)
+	}
+
 	// add - if struct is not empty do this. otherwise, dont write anything.
 	m.output.Headers = []string{
 		"Account",
@@ -195,10 +274,24 @@ func (m *CodeBuildModule) PrintCodeBuildProjects(outputDirectory string, verbosi
 			TableCols: tableCols,
 			Name:      m.output.CallingModule,
 		})
+
+		if findingsHeader, findingsBody := m.privescFindingsTable(); len(findingsBody) > 0 {
+			o.Table.TableFiles = append(o.Table.TableFiles, internal.TableFile{
+				Header:    findingsHeader,
+				Body:      findingsBody,
+				TableCols: findingsHeader,
+				Name:      "codebuild-privesc-findings",
+			})
+		}
+
 		o.PrefixIdentifier = m.AWSProfile
 		o.Table.DirectoryName = filepath.Join(outputDirectory, "cloudfox-output", "aws", fmt.Sprintf("%s-%s", m.AWSProfile, aws.ToString(m.Caller.Account)))
 		o.WriteFullOutput(o.Table.TableFiles, nil)
-		//m.writeLoot(o.Table.DirectoryName, verbosity)
+
+		if err := m.writeGraphObjects(o.Table.DirectoryName); err != nil {
+			m.modLog.Errorf("writing codebuildProjects.jsonl for graph ingestion: %s", err)
+		}
+
 		fmt.Printf("[%s][%s] %d projects found.\n", cyan(m.output.CallingModule), cyan(m.AWSProfile), len(m.output.Body))
 		fmt.Printf("[%s][%s] For context and next steps: https://github.com/BishopFox/cloudfox/wiki/AWS-Commands#%s\n", cyan(m.output.CallingModule), cyan(m.AWSProfile), m.output.CallingModule)
 
@@ -208,6 +301,96 @@ func (m *CodeBuildModule) PrintCodeBuildProjects(outputDirectory string, verbosi
 
 }
 
+// privescFindingsTable builds the rows for the codebuild-privesc-findings
+// table: only projects flagged as hijack targets in PrintCodeBuildProjects.
+func (m *CodeBuildModule) privescFindingsTable() ([]string, [][]string) {
+	header := []string{
+		"Account",
+		"Region",
+		"Name",
+		"Role",
+		"SourceType",
+		"SourceLocation",
+		"PRTriggered",
+		"PrivilegedMode",
+		"HasPlaintextSecret",
+		"SecondarySources",
+		"Buildspec",
+	}
+
+	var body [][]string
+	for _, project := range m.Projects {
+		if !project.IsHijackTarget {
+			continue
+		}
+		body = append(body, []string{
+			aws.ToString(m.Caller.Account),
+			project.Region,
+			project.Name,
+			project.Role,
+			project.SourceType,
+			project.SourceLocation,
+			fmt.Sprintf("%t", project.IsPRTriggered),
+			fmt.Sprintf("%t", project.PrivilegedMode),
+			fmt.Sprintf("%t", project.HasPlaintextSecret),
+			strings.Join(project.SecondarySources, ";"),
+			project.Buildspec,
+		})
+	}
+	return header, body
+}
+
+// writeGraphObjects writes one models.CodeBuildProject per line to
+// codebuildProjects.jsonl next to the module's table output, so a later
+// `cloudfox graph ingest` run against this output directory picks up
+// CodeBuildProject nodes the same way it already does accounts and roles.
+func (m *CodeBuildModule) writeGraphObjects(directory string) error {
+	path := filepath.Join(directory, "codebuildProjects.jsonl")
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	for _, project := range m.Projects {
+		var secretNames []string
+		for _, envVar := range project.EnvironmentVariables {
+			if envVar.IsSecret {
+				secretNames = append(secretNames, envVar.Name)
+			}
+		}
+
+		var webhookFilterGroups []string
+		for _, filter := range project.WebhookFilterGroups {
+			webhookFilterGroups = append(webhookFilterGroups, fmt.Sprintf("%s:%s", filter.Type, filter.Pattern))
+		}
+
+		node := models.CodeBuildProject{
+			Id:                  project.Arn,
+			Name:                project.Name,
+			Region:              project.Region,
+			Role:                project.Role,
+			SourceType:          project.SourceType,
+			SourceLocation:      project.SourceLocation,
+			SourceAuthType:      project.SourceAuthType,
+			WebhookEnabled:      project.WebhookEnabled,
+			IsPRTriggered:       project.IsPRTriggered,
+			PrivilegedMode:      project.PrivilegedMode,
+			HasPlaintextSecret:  project.HasPlaintextSecret,
+			SecretEnvVarNames:   secretNames,
+			IsHijackTarget:      project.IsHijackTarget,
+			Buildspec:           project.Buildspec,
+			SecondarySources:    project.SecondarySources,
+			WebhookFilterGroups: webhookFilterGroups,
+		}
+		if err := encoder.Encode(node); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (m *CodeBuildModule) executeChecks(r string, wg *sync.WaitGroup, semaphore chan struct{}, dataReceiver chan Project) {
 	defer wg.Done()
 
@@ -255,14 +438,174 @@ func (m *CodeBuildModule) getcodeBuildProjectsPerRegion(r string, wg *sync.WaitG
 			sharedLogger.Error(err.Error())
 		}
 
-		dataReceiver <- Project{
-			Name:       aws.ToString(details.Name),
-			Region:     r,
-			Role:       aws.ToString(details.ServiceRole),
-			Admin:      "",
-			CanPrivEsc: "",
+		dataReceiver <- m.buildProjectRecord(r, details)
+	}
+
+}
+
+// buildProjectRecord flattens an AWS CodeBuild project's source, webhook,
+// environment, and secondary-source configuration into a Project so the rest
+// of the module doesn't need to know about the SDK's nested types.
+func (m *CodeBuildModule) buildProjectRecord(region string, details types.Project) Project {
+	p := Project{
+		Name:       aws.ToString(details.Name),
+		Region:     region,
+		Arn:        aws.ToString(details.Arn),
+		Role:       aws.ToString(details.ServiceRole),
+		Admin:      "",
+		CanPrivEsc: "",
+	}
+
+	if details.Source != nil {
+		p.SourceType = string(details.Source.Type)
+		p.SourceLocation = aws.ToString(details.Source.Location)
+		p.Buildspec = aws.ToString(details.Source.Buildspec)
+		if details.Source.Auth != nil {
+			p.SourceAuthType = string(details.Source.Auth.Type)
+		}
+		if m.FetchBuildspecs && (p.Buildspec == "" || !strings.Contains(p.Buildspec, "version:")) {
+			// An empty (or path-only) buildspec means the real one lives in
+			// the source repo -- go fetch it so the operator can review it
+			// without pulling the repo themselves. Gated behind
+			// FetchBuildspecs since this is an extra outbound call per
+			// project that the operator should opt into.
+			if fetched, err := m.fetchBuildspec(region, p.SourceType, p.SourceLocation, p.Buildspec); err == nil {
+				p.Buildspec = fetched
+			} else {
+				m.modLog.Debugf("could not fetch buildspec for %s: %s", p.Name, err)
+			}
+		}
+	}
+
+	for _, secondary := range details.SecondarySources {
+		p.SecondarySources = append(p.SecondarySources, aws.ToString(secondary.Location))
+	}
+
+	if details.Environment != nil {
+		if details.Environment.PrivilegedMode != nil {
+			p.PrivilegedMode = *details.Environment.PrivilegedMode
+		}
+		for _, envVar := range details.Environment.EnvironmentVariables {
+			name := aws.ToString(envVar.Name)
+			envType := string(envVar.Type)
+			isSecret := envType == string(types.EnvironmentVariableTypePlaintext) && m.secretRegex != nil && m.secretRegex.MatchString(name)
+			if isSecret {
+				p.HasPlaintextSecret = true
+			}
+			p.EnvironmentVariables = append(p.EnvironmentVariables, EnvironmentVariable{
+				Name:     name,
+				Type:     envType,
+				Value:    aws.ToString(envVar.Value),
+				IsSecret: isSecret,
+			})
+		}
+	}
+
+	if details.Webhook != nil {
+		p.WebhookEnabled = true
+		for _, group := range details.Webhook.FilterGroups {
+			for _, filter := range group {
+				pattern := aws.ToString(filter.Pattern)
+				p.WebhookFilterGroups = append(p.WebhookFilterGroups, WebhookFilterGroup{
+					Type:    string(filter.Type),
+					Pattern: pattern,
+				})
+				if filter.Type == types.WebhookFilterTypeEvent && strings.Contains(pattern, "PULL_REQUEST") {
+					p.IsPRTriggered = true
+				}
+			}
 		}
+	}
+
+	return p
+}
+
+// fetchBuildspec best-effort retrieves buildspec.yml from the project's
+// source when CodeBuild doesn't have it inline: an S3 "bucket/prefix" source
+// location (the buildspec path is read under that prefix), or a public
+// GitHub repo. Anything else (CodePipeline, private auth'd
+// sources) is left for the operator to pull themselves.
+func (m *CodeBuildModule) fetchBuildspec(region string, sourceType string, sourceLocation string, buildspecPath string) (string, error) {
+	if buildspecPath == "" {
+		buildspecPath = "buildspec.yml"
+	}
+
+	switch sourceType {
+	case string(types.SourceTypeS3):
+		return m.fetchBuildspecFromS3(region, sourceLocation, buildspecPath)
+	case string(types.SourceTypeGithub), string(types.SourceTypeGithubEnterprise):
+		return fetchBuildspecFromGitHub(sourceLocation, buildspecPath)
+	default:
+		return "", fmt.Errorf("unsupported source type %q for buildspec fetch", sourceType)
+	}
+}
+
+// fetchBuildspecFromS3 reads the buildspec through m.S3Client, the same
+// caller-scoped client (and therefore the same profile/credentials) used for
+// the rest of the enumeration, rather than resolving a fresh default AWS
+// config that may belong to a different identity.
+func (m *CodeBuildModule) fetchBuildspecFromS3(region string, sourceLocation string, buildspecPath string) (string, error) {
+	bucket, prefix, found := strings.Cut(sourceLocation, "/")
+	if !found || bucket == "" {
+		return "", fmt.Errorf("invalid S3 source location %q", sourceLocation)
+	}
+	key := buildspecPath
+	if prefix != "" {
+		key = strings.TrimSuffix(prefix, "/") + "/" + buildspecPath
+	}
+
+	body, err := sdk.CachedS3GetObject(m.S3Client, aws.ToString(m.Caller.Account), region, bucket, key)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+// buildspecHTTPClient bounds the GitHub raw-content fetch so one slow or
+// hanging response can't wedge the per-region enumeration goroutine that
+// triggered it.
+var buildspecHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+func fetchBuildspecFromGitHub(sourceLocation string, buildspecPath string) (string, error) {
+	rawURL, err := githubRawURL(sourceLocation, buildspecPath)
+	if err != nil {
+		return "", err
+	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := buildspecHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetching %s returned status %d", rawURL, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
 	}
+	return string(body), nil
+}
 
+// githubRawURL turns a GitHub repo URL into a raw.githubusercontent.com URL
+// for buildspecPath on the repo's default branch.
+func githubRawURL(repoURL string, buildspecPath string) (string, error) {
+	repo := strings.TrimSuffix(repoURL, ".git")
+	repo = strings.TrimPrefix(repo, "https://github.com/")
+	repo = strings.TrimPrefix(repo, "http://github.com/")
+	repo = strings.TrimPrefix(repo, "git@github.com:")
+	if repo == repoURL || repo == "" {
+		return "", fmt.Errorf("unrecognized GitHub source location %q", repoURL)
+	}
+	return fmt.Sprintf("https://raw.githubusercontent.com/%s/HEAD/%s", repo, buildspecPath), nil
 }
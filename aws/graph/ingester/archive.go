@@ -0,0 +1,258 @@
+package ingestor
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	log "github.com/sirupsen/logrus"
+)
+
+// resolveSource normalizes source into a local directory of JSONL files ready
+// for filepath.Walk, transparently handling a plain directory, a .zip or
+// .tar.gz archive, or an s3://bucket/key archive. The returned cleanup func
+// removes any temporary directory created along the way and is always safe
+// to call, even when nil work was done.
+func (i *CloudFoxIngestor) resolveSource(source string) (string, func(), error) {
+	noop := func() {}
+
+	if strings.HasPrefix(source, "s3://") {
+		localArchive, err := i.downloadFromS3(source)
+		if err != nil {
+			return "", noop, err
+		}
+		defer os.RemoveAll(filepath.Dir(localArchive))
+		return i.resolveSource(localArchive)
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", noop, err
+	}
+
+	if info.IsDir() {
+		return source, noop, nil
+	}
+
+	switch {
+	case strings.HasSuffix(source, ".zip"):
+		dir, err := i.unzipToTemp(source)
+		if err != nil {
+			return "", noop, err
+		}
+		return dir, func() { os.RemoveAll(dir) }, nil
+	case strings.HasSuffix(source, ".tar.gz") || strings.HasSuffix(source, ".tgz"):
+		dir, err := i.untarToTemp(source)
+		if err != nil {
+			return "", noop, err
+		}
+		return dir, func() { os.RemoveAll(dir) }, nil
+	default:
+		return "", noop, fmt.Errorf("unsupported graph source %q: expected a directory, .zip, .tar.gz, or s3:// URI", source)
+	}
+}
+
+func (i *CloudFoxIngestor) tempDir(pattern string) (string, error) {
+	base := i.TmpDir
+	if base != "" {
+		if err := os.MkdirAll(base, 0o755); err != nil {
+			return "", err
+		}
+	}
+	return os.MkdirTemp(base, pattern)
+}
+
+func (i *CloudFoxIngestor) unzipToTemp(zipFilePath string) (string, error) {
+	tempDir, err := i.tempDir("cloudfox-graph-zip")
+	if err != nil {
+		return "", err
+	}
+
+	zipfile, err := zip.OpenReader(zipFilePath)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return "", err
+	}
+	defer zipfile.Close()
+
+	for _, file := range zipfile.File {
+		path := filepath.Join(tempDir, file.Name)
+		if !strings.HasPrefix(path, filepath.Clean(tempDir)+string(os.PathSeparator)) {
+			return "", fmt.Errorf("invalid file path in zip archive: %s", file.Name)
+		}
+		log.Debugf("Extracting file: %s", path)
+
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return "", err
+		}
+
+		if err := extractZipEntry(file, path); err != nil {
+			return "", err
+		}
+	}
+	return tempDir, nil
+}
+
+func extractZipEntry(file *zip.File, path string) error {
+	fileData, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer fileData.Close()
+
+	newFile, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer newFile.Close()
+
+	_, err = io.Copy(newFile, fileData)
+	return err
+}
+
+func (i *CloudFoxIngestor) untarToTemp(archivePath string) (string, error) {
+	tempDir, err := i.tempDir("cloudfox-graph-tar")
+	if err != nil {
+		return "", err
+	}
+
+	file, err := os.Open(archivePath)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return "", err
+	}
+	defer file.Close()
+
+	gzReader, err := gzip.NewReader(file)
+	if err != nil {
+		os.RemoveAll(tempDir)
+		return "", err
+	}
+	defer gzReader.Close()
+
+	tarReader := tar.NewReader(gzReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+
+		path := filepath.Join(tempDir, header.Name)
+		if !strings.HasPrefix(path, filepath.Clean(tempDir)+string(os.PathSeparator)) {
+			return "", fmt.Errorf("invalid file path in tar archive: %s", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return "", err
+			}
+		case tar.TypeReg:
+			log.Debugf("Extracting file: %s", path)
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return "", err
+			}
+			newFile, err := os.Create(path)
+			if err != nil {
+				return "", err
+			}
+			if _, err := io.Copy(newFile, tarReader); err != nil {
+				newFile.Close()
+				return "", err
+			}
+			newFile.Close()
+		}
+	}
+	return tempDir, nil
+}
+
+// downloadFromS3 fetches an s3://bucket/key archive to a local temp file,
+// streaming the body to disk rather than buffering it in memory so large
+// multi-account dumps don't blow up the analyst's workstation.
+func (i *CloudFoxIngestor) downloadFromS3(source string) (string, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("invalid s3 URI %q: %w", source, err)
+	}
+	bucket := u.Host
+	key := strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return "", fmt.Errorf("invalid s3 URI %q: expected s3://bucket/key", source)
+	}
+
+	goCtx := context.Background()
+	var opts []func(*awsconfig.LoadOptions) error
+	if i.AWSProfile != "" {
+		opts = append(opts, awsconfig.WithSharedConfigProfile(i.AWSProfile))
+	}
+	cfg, err := awsconfig.LoadDefaultConfig(goCtx, opts...)
+	if err != nil {
+		return "", fmt.Errorf("loading AWS config to download %s: %w", source, err)
+	}
+	client := s3.NewFromConfig(cfg)
+
+	// The bucket may live outside cfg's region, which GetObject would
+	// otherwise fail on with a redirect error -- resolve its actual region
+	// first and use a client pinned to it.
+	bucketRegion, err := manager.GetBucketRegion(goCtx, client, bucket)
+	if err != nil {
+		return "", fmt.Errorf("resolving region for bucket %q: %w", bucket, err)
+	}
+	if bucketRegion != cfg.Region {
+		client = s3.NewFromConfig(cfg, func(o *s3.Options) { o.Region = bucketRegion })
+	}
+
+	out, err := client.GetObject(goCtx, &s3.GetObjectInput{
+		Bucket: &bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return "", fmt.Errorf("downloading %s: %w", source, err)
+	}
+	defer out.Body.Close()
+
+	dir, err := i.tempDir("cloudfox-graph-s3")
+	if err != nil {
+		return "", err
+	}
+
+	localPath := filepath.Join(dir, filepath.Base(key))
+	localFile, err := os.Create(localPath)
+	if err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	defer localFile.Close()
+
+	log.Infof("Downloading %s to %s", source, localPath)
+	writer := bufio.NewWriter(localFile)
+	if _, err := io.Copy(writer, out.Body); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("writing %s: %w", localPath, err)
+	}
+	if err := writer.Flush(); err != nil {
+		os.RemoveAll(dir)
+		return "", err
+	}
+	return localPath, nil
+}
@@ -4,34 +4,24 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/BishopFox/cloudfox/aws/graph/ingester/schema"
 	"github.com/BishopFox/cloudfox/aws/graph/ingester/schema/models"
+	"github.com/BishopFox/cloudfox/aws/graph/repository"
 	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
 	log "github.com/sirupsen/logrus"
 )
 
 const (
 	// Neo4j
-	MergeNodeQueryTemplate = `CALL apoc.merge.node([$labels[0]], {Id: $Id}, $properties, $properties) YIELD node as obj
-	CALL apoc.create.setLabels(obj, $labels) YIELD node as labeledObj
-	RETURN labeledObj`
-
-	MergeRelationQueryTemplate = `UNWIND $batch as row
-	CALL apoc.merge.node([row.sourceLabel], apoc.map.fromValues([row.sourceProperty, row.sourceNodeId])) YIELD node as from
-	CALL apoc.merge.node([row.targetLabel], apoc.map.fromValues([row.targetProperty, row.targetNodeId])) YIELD node as to
-	CALL apoc.merge.relationship(from, row.relationshipType, {}, row.properties, to) YIELD rel
-	RETURN rel`
-
-	// Using sprintf to insert the label name since the driver doesn't support parameters for labels here
-	// %[1]s is a nice way to say "insert the first parameter here"
-	CreateConstraintQueryTemplate = "CREATE CONSTRAINT IF NOT EXISTS FOR (n: %s) REQUIRE n.Id IS UNIQUE"
-	CreateIndexQueryTemplate      = "CREATE INDEX %[1]s_Id IF NOT EXISTS FOR (n: %[1]s) ON (n.Id)"
-
 	PostProcessMergeQueryTemplate = `MATCH (n)
 	WITH n.Id AS Id, COLLECT(n) AS nodesToMerge
 	WHERE size(nodesToMerge) > 1
@@ -40,6 +30,16 @@ const (
 	RETURN count(*);`
 )
 
+// defaultBatchSize and defaultConcurrency bound how many rows go into a single
+// UNWIND and how many batches run against the driver at once, respectively.
+// They're conservative defaults; operators loading very large dumps should
+// tune CloudFoxIngestor.BatchSize/Concurrency for their Neo4j instance.
+const (
+	defaultBatchSize       = 1000
+	defaultConcurrency     = 4
+	progressReportInterval = 5 * time.Second
+)
+
 type Neo4jConfig struct {
 	Uri      string
 	Username string
@@ -51,6 +51,42 @@ type CloudFoxIngestor struct {
 	//ResultsFile string
 	Driver neo4j.DriverWithContext
 	TmpDir string
+
+	// BatchSize is the number of rows merged per UNWIND query. Defaults to
+	// defaultBatchSize when <= 0.
+	BatchSize int
+	// Concurrency is the number of batches that may be in flight against
+	// Neo4j at once, per file. Defaults to defaultConcurrency when <= 0.
+	Concurrency int
+
+	// VerifyAfterIngest runs the post-ingest structural checks in
+	// verificationChecks once the merge query finishes, and fails Run if any
+	// non-advisory check finds offending records. Off by default since it adds another
+	// pass over the whole graph.
+	VerifyAfterIngest bool
+
+	// AWSProfile is the named profile used to resolve credentials when
+	// source is an s3:// URI. Empty uses the default credential chain, same
+	// as every other cloudfox module when no -p is given.
+	AWSProfile string
+
+	// repositories holds one repository per node label, built by Run. All
+	// reads and writes to Neo4j go through these rather than ad hoc Cypher.
+	repositories map[schema.NodeLabel]repository.Repository
+}
+
+func (i *CloudFoxIngestor) batchSize() int {
+	if i.BatchSize > 0 {
+		return i.BatchSize
+	}
+	return defaultBatchSize
+}
+
+func (i *CloudFoxIngestor) concurrency() int {
+	if i.Concurrency > 0 {
+		return i.Concurrency
+	}
+	return defaultConcurrency
 }
 
 func NewCloudFoxIngestor() (*CloudFoxIngestor, error) {
@@ -70,43 +106,6 @@ func NewCloudFoxIngestor() (*CloudFoxIngestor, error) {
 	}, nil
 }
 
-// func unzipToTemp(zipFilePath string) (string, error) {
-// 	// Create a temporary directory to extract the zip file to
-// 	tempDir, err := os.MkdirTemp("", "cloudfox-graph")
-// 	if err != nil {
-// 		return "", err
-// 	}
-
-// 	// Open the zip file and extract to a temporary directory
-// 	zipfile, err := zip.OpenReader(zipFilePath)
-// 	if err != nil {
-// 		return "", err
-// 	}
-// 	defer zipfile.Close()
-
-// 	for _, file := range zipfile.File {
-// 		path := filepath.Join(tempDir, file.Name)
-// 		log.Debugf("Extracting file: %s", path)
-
-// 		fileData, err := file.Open()
-// 		if err != nil {
-// 			return "", err
-// 		}
-// 		defer fileData.Close()
-
-// 		newFile, err := os.Create(path)
-// 		if err != nil {
-// 			return "", err
-// 		}
-// 		defer newFile.Close()
-
-// 		if _, err := io.Copy(newFile, fileData); err != nil {
-// 			return "", err
-// 		}
-// 	}
-// 	return tempDir, nil
-// }
-
 func (i *CloudFoxIngestor) ProcessFile(path string, info os.FileInfo) error {
 	log.Infof("Processing file: %s", info.Name())
 
@@ -115,6 +114,8 @@ func (i *CloudFoxIngestor) ProcessFile(path string, info os.FileInfo) error {
 		return i.ProcessFileObjects(path, schema.Account, schema.Account)
 	case "roles.jsonl":
 		return i.ProcessFileObjects(path, schema.Role, schema.Role)
+	case "codebuildProjects.jsonl":
+		return i.ProcessFileObjects(path, schema.CodeBuildProject, schema.CodeBuildProject)
 	// case "servicePrincipals.jsonl":
 	// 	return i.ProcessFileObjects(path, schema.GraphServicePrincipal, schema.GraphObject)
 	// case "applications.jsonl":
@@ -134,9 +135,51 @@ func (i *CloudFoxIngestor) ProcessFile(path string, info os.FileInfo) error {
 	}
 }
 
+// newObject returns a fresh zero-value instance of the same concrete type as
+// prototype, so each line is unmarshalled into its own struct rather than one
+// shared with batches still in flight or with another file of the same kind
+// that Run is processing concurrently.
+func newObject(prototype schema.Node) schema.Node {
+	return reflect.New(reflect.TypeOf(prototype).Elem()).Interface().(schema.Node)
+}
+
+// countLines does a cheap newline count up front so the progress reporter can
+// compute an ETA; it's not exact for files without a trailing newline, which
+// is fine for a progress estimate.
+func countLines(path string) int64 {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+
+	var count int64
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := file.Read(buf)
+		count += int64(strings.Count(string(buf[:n]), "\n"))
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count
+		}
+	}
+	return count
+}
+
+// ProcessFileObjects is a thin dispatcher: it turns each JSONL line into a
+// node/relationship batch and hands the batches to the objectType's
+// repository, which owns the actual Cypher.
 func (i *CloudFoxIngestor) ProcessFileObjects(path string, objectType schema.NodeLabel, generalType schema.NodeLabel) error {
 
-	var object = models.NodeLabelToNodeMap[objectType]
+	var prototype = models.NodeLabelToNodeMap[objectType]
+	labels := []schema.NodeLabel{generalType, objectType}
+
+	repo, ok := i.repositories[objectType]
+	if !ok {
+		return fmt.Errorf("no repository registered for node label %s", objectType)
+	}
 
 	// Open the file
 	file, err := os.Open(path)
@@ -145,63 +188,62 @@ func (i *CloudFoxIngestor) ProcessFileObjects(path string, objectType schema.Nod
 	}
 	defer file.Close()
 
+	fileName := filepath.Base(path)
+	progress := newProgressReporter(fileName, countLines(path))
+	progress.Start(progressReportInterval)
+	defer progress.Stop()
+
+	batchSize := i.batchSize()
+	wg := new(sync.WaitGroup)
+	semaphore := make(chan struct{}, i.concurrency())
+
+	var nodeBatch []map[string]interface{}
+	var relBatch []map[string]interface{}
+
+	flush := func(nodes []map[string]interface{}, rels []map[string]interface{}) {
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			insertNodeBatch(repo, nodes, progress)
+			insertRelationshipBatch(repo, rels, progress)
+		}()
+	}
+
 	// Read the file line by line
 	scanner := bufio.NewScanner(file)
 
 	//Iterate over the lines and create the nodes
 	for scanner.Scan() {
 		line := strings.TrimSuffix(scanner.Text(), "\n")
+		progress.AddLines(1)
 
 		// Skip empty lines
-		if len(line) > 0 {
-			if err := json.Unmarshal([]byte(line), &object); err != nil {
-				log.Errorf("%s : %s", err, line)
-				continue
-			}
-		}
-		relationships := object.MakeRelationships()
-		if err := i.InsertDBObjects(object, relationships, []schema.NodeLabel{generalType, objectType}); err != nil {
-			log.Error(err)
+		if len(line) == 0 {
 			continue
 		}
 
-	}
-	return nil
-}
-
-func (i *CloudFoxIngestor) InsertDBObjects(object schema.Node, relationships []schema.Relationship, labels []schema.NodeLabel) error {
-	goCtx := context.Background()
-	var err error
+		object := newObject(prototype)
+		if err := json.Unmarshal([]byte(line), &object); err != nil {
+			log.Errorf("%s : %s", err, line)
+			progress.AddErrors(1)
+			continue
+		}
 
-	// Insert the node
-	if object != nil {
 		nodeMap, err := schema.ConvertCustomTypesToNeo4j(&object)
 		if err != nil {
 			log.Errorf("Error converting custom types to neo4j: %s -- %v", err, object)
-			return err
+			progress.AddErrors(1)
+			continue
 		}
-
-		//nodeMap := schema.AsNeo4j(&object)
-		nodeQueryParams := map[string]interface{}{
+		nodeBatch = append(nodeBatch, map[string]interface{}{
 			"Id":         nodeMap["Id"],
 			"labels":     labels,
 			"properties": nodeMap,
-		}
-		_, err = neo4j.ExecuteQuery(goCtx, i.Driver, MergeNodeQueryTemplate, nodeQueryParams, neo4j.EagerResultTransformer, neo4j.ExecuteQueryWithDatabase("neo4j"))
-		if err != nil {
-			log.Errorf("Error inserting node: %s -- %v", err, nodeQueryParams)
-			return err
-		}
-	}
-
-	// Insert the relationships
-	if len(relationships) > 0 {
-		var relationshipInterface []map[string]interface{}
-
-		// Check the default SourceProperty and TargetProperty values
-		for _, relationship := range relationships {
-			var currentRelationship map[string]interface{}
+		})
 
+		for _, relationship := range object.MakeRelationships() {
 			if relationship.SourceProperty == "" {
 				relationship.SourceProperty = "Id"
 			}
@@ -210,25 +252,66 @@ func (i *CloudFoxIngestor) InsertDBObjects(object schema.Node, relationships []s
 			}
 			relationshipBytes, err := json.Marshal(relationship)
 			if err != nil {
-				return err
+				log.Errorf("Error marshalling relationship: %s -- %v", err, relationship)
+				progress.AddErrors(1)
+				continue
 			}
+			var currentRelationship map[string]interface{}
 			if err := json.Unmarshal(relationshipBytes, &currentRelationship); err != nil {
-				return err
+				log.Errorf("Error unmarshalling relationship: %s -- %v", err, relationship)
+				progress.AddErrors(1)
+				continue
 			}
-			relationshipInterface = append(relationshipInterface, currentRelationship)
+			relBatch = append(relBatch, currentRelationship)
 		}
 
-		_, err = neo4j.ExecuteQuery(goCtx, i.Driver, MergeRelationQueryTemplate, map[string]interface{}{"batch": relationshipInterface}, neo4j.EagerResultTransformer, neo4j.ExecuteQueryWithDatabase("neo4j"))
-		if err != nil {
-			log.Errorf("Error inserting relationships: %s -- %v", err, relationshipInterface)
-			return err
+		if len(nodeBatch) >= batchSize || len(relBatch) >= batchSize {
+			flush(nodeBatch, relBatch)
+			nodeBatch = nil
+			relBatch = nil
 		}
 	}
+	if len(nodeBatch) > 0 || len(relBatch) > 0 {
+		flush(nodeBatch, relBatch)
+	}
 
+	wg.Wait()
 	return nil
 }
 
-func (i *CloudFoxIngestor) Run(graphOutputDir string) error {
+// insertNodeBatch hands a batch of nodes to repo. Individual batch failures
+// are logged once, aggregated, rather than per row, to avoid flooding the
+// terminal on large ingests.
+func insertNodeBatch(repo repository.Repository, batch []map[string]interface{}, progress *progressReporter) {
+	if len(batch) == 0 {
+		return
+	}
+	if err := repo.UpsertNodes(context.Background(), batch); err != nil {
+		log.Errorf("Error inserting batch of %d %s nodes: %s", len(batch), repo.Label(), err)
+		progress.AddErrors(1)
+		return
+	}
+	progress.AddNodes(int64(len(batch)))
+}
+
+// insertRelationshipBatch hands a batch of relationships to repo, mirroring
+// insertNodeBatch.
+func insertRelationshipBatch(repo repository.Repository, batch []map[string]interface{}, progress *progressReporter) {
+	if len(batch) == 0 {
+		return
+	}
+	if err := repo.UpsertRelationships(context.Background(), batch); err != nil {
+		log.Errorf("Error inserting batch of %d relationships from %s: %s", len(batch), repo.Label(), err)
+		progress.AddErrors(1)
+		return
+	}
+	progress.AddRelations(int64(len(batch)))
+}
+
+// Run ingests the graph data found at source, which may be a directory of
+// JSONL files, a .zip or .tar.gz archive of one, or an s3://bucket/key
+// pointing at such an archive.
+func (i *CloudFoxIngestor) Run(source string) error {
 	goCtx := context.Background()
 	log.Infof("Verifying connectivity to Neo4J at %s", i.Uri)
 	if err := i.Driver.VerifyConnectivity(goCtx); err != nil {
@@ -237,19 +320,17 @@ func (i *CloudFoxIngestor) Run(graphOutputDir string) error {
 	defer i.Driver.Close(goCtx)
 	var err error
 
-	// Get the label to model map
+	graphOutputDir, cleanup, err := i.resolveSource(source)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	i.repositories = repository.New(i.Driver, "neo4j")
 
-	// Create constraints and indexes
-	// log.Info("Creating constraints and indexes for labels")
-	// for label := range models.NodeLabelToNodeMap {
-	// 	for _, query := range []string{CreateConstraintQueryTemplate, CreateIndexQueryTemplate} {
-	// 		_, err := neo4j.ExecuteQuery(goCtx, i.Driver, fmt.Sprintf(query, label), nil, neo4j.EagerResultTransformer, neo4j.ExecuteQueryWithDatabase("neo4j"))
-	// 		if err != nil {
-	// 			log.Error(err)
-	// 			continue
-	// 		}
-	// 	}
-	// }
+	if err := i.EnsureSchema(); err != nil {
+		return err
+	}
 
 	// Process the files in the output directory
 	fileWg := new(sync.WaitGroup)
@@ -279,5 +360,11 @@ func (i *CloudFoxIngestor) Run(graphOutputDir string) error {
 		log.Error(err)
 		return err
 	}
+
+	if i.VerifyAfterIngest {
+		if _, err := i.Verify(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,97 @@
+package ingestor
+
+import (
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// progressReporter tracks ingest throughput for a single file and periodically
+// logs nodes/sec, relationships/sec, and an ETA based on lines consumed so far.
+type progressReporter struct {
+	fileName  string
+	startTime time.Time
+
+	totalLines int64
+	lines      int64
+	nodes      int64
+	relations  int64
+	errors     int64
+
+	ticker *time.Ticker
+	done   chan struct{}
+}
+
+func newProgressReporter(fileName string, totalLines int64) *progressReporter {
+	return &progressReporter{
+		fileName:   fileName,
+		totalLines: totalLines,
+		done:       make(chan struct{}),
+	}
+}
+
+// Start begins emitting progress updates every interval until Stop is called.
+func (p *progressReporter) Start(interval time.Duration) {
+	p.startTime = time.Now()
+	p.ticker = time.NewTicker(interval)
+	go func() {
+		for {
+			select {
+			case <-p.ticker.C:
+				p.report(false)
+			case <-p.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the ticker and emits one final summary line for the file.
+func (p *progressReporter) Stop() {
+	if p.ticker != nil {
+		p.ticker.Stop()
+	}
+	close(p.done)
+	p.report(true)
+}
+
+func (p *progressReporter) AddLines(n int64)     { atomic.AddInt64(&p.lines, n) }
+func (p *progressReporter) AddNodes(n int64)     { atomic.AddInt64(&p.nodes, n) }
+func (p *progressReporter) AddRelations(n int64) { atomic.AddInt64(&p.relations, n) }
+func (p *progressReporter) AddErrors(n int64)    { atomic.AddInt64(&p.errors, n) }
+
+func (p *progressReporter) report(final bool) {
+	elapsed := time.Since(p.startTime).Seconds()
+	if elapsed <= 0 {
+		elapsed = 1
+	}
+
+	lines := atomic.LoadInt64(&p.lines)
+	nodes := atomic.LoadInt64(&p.nodes)
+	relations := atomic.LoadInt64(&p.relations)
+	errors := atomic.LoadInt64(&p.errors)
+
+	nodesPerSec := float64(nodes) / elapsed
+	relsPerSec := float64(relations) / elapsed
+
+	eta := "unknown"
+	if p.totalLines > 0 && lines > 0 && !final {
+		linesPerSec := float64(lines) / elapsed
+		remaining := p.totalLines - lines
+		if linesPerSec > 0 && remaining > 0 {
+			eta = time.Duration(float64(remaining) / linesPerSec * float64(time.Second)).Round(time.Second).String()
+		} else {
+			eta = "0s"
+		}
+	}
+
+	verb := "Ingesting"
+	if final {
+		verb = "Finished ingesting"
+		eta = "0s"
+	}
+
+	log.Infof("[graph] %s %s: %d/%d lines, %.1f nodes/sec, %.1f rels/sec, %d errors, ETA %s",
+		verb, p.fileName, lines, p.totalLines, nodesPerSec, relsPerSec, errors, eta)
+}
@@ -0,0 +1,11 @@
+package schema
+
+// Labels introduced for CodeBuild privilege-escalation surface mapping.
+// CodeRepository and Secret are relationship endpoints only -- they're never
+// ingested from their own JSONL file, just merged in place by
+// CodeBuildProject's MakeRelationships.
+const (
+	CodeBuildProject NodeLabel = "CodeBuildProject"
+	CodeRepository   NodeLabel = "CodeRepository"
+	Secret           NodeLabel = "Secret"
+)
@@ -0,0 +1,93 @@
+package models
+
+import "github.com/BishopFox/cloudfox/aws/graph/ingester/schema"
+
+// CodeBuildProject mirrors the fields the codebuild module writes to
+// codebuildProjects.jsonl -- see aws.Project for the enumeration side.
+type CodeBuildProject struct {
+	Id             string `json:"Id"`
+	Name           string `json:"Name"`
+	Region         string `json:"Region"`
+	Role           string `json:"Role"`
+	SourceType     string `json:"SourceType"`
+	SourceLocation string `json:"SourceLocation"`
+	SourceAuthType string `json:"SourceAuthType"`
+
+	WebhookEnabled bool `json:"WebhookEnabled"`
+	IsPRTriggered  bool `json:"IsPRTriggered"`
+	PrivilegedMode bool `json:"PrivilegedMode"`
+
+	HasPlaintextSecret bool     `json:"HasPlaintextSecret"`
+	SecretEnvVarNames  []string `json:"SecretEnvVarNames"`
+	IsHijackTarget     bool     `json:"IsHijackTarget"`
+
+	// Buildspec is the raw buildspec.yml content, when the CodeBuild API
+	// returned it inline or the codebuild module fetched it from the
+	// project's source, so an operator can review it without pulling the
+	// repo themselves.
+	Buildspec           string   `json:"Buildspec,omitempty"`
+	SecondarySources    []string `json:"SecondarySources,omitempty"`
+	WebhookFilterGroups []string `json:"WebhookFilterGroups,omitempty"`
+}
+
+// MakeRelationships wires a CodeBuildProject to the role it builds as, the
+// source repo it reads (if any), and any plaintext secret env vars it
+// exposes -- the same combination PrintCodeBuildProjects flags as a hijack
+// target.
+func (p *CodeBuildProject) MakeRelationships() []schema.Relationship {
+	var relationships []schema.Relationship
+
+	if p.Role != "" {
+		relationships = append(relationships, schema.Relationship{
+			SourceLabel:      schema.CodeBuildProject,
+			SourceProperty:   "Id",
+			SourceNodeId:     p.Id,
+			TargetLabel:      schema.Role,
+			TargetProperty:   "Id",
+			TargetNodeId:     p.Role,
+			RelationshipType: "USES_ROLE",
+		})
+	}
+
+	if p.SourceLocation != "" {
+		relationships = append(relationships, schema.Relationship{
+			SourceLabel:      schema.CodeBuildProject,
+			SourceProperty:   "Id",
+			SourceNodeId:     p.Id,
+			TargetLabel:      schema.CodeRepository,
+			TargetProperty:   "Id",
+			TargetNodeId:     p.SourceLocation,
+			RelationshipType: "READS_SOURCE",
+		})
+	}
+
+	for _, secondarySource := range p.SecondarySources {
+		relationships = append(relationships, schema.Relationship{
+			SourceLabel:      schema.CodeBuildProject,
+			SourceProperty:   "Id",
+			SourceNodeId:     p.Id,
+			TargetLabel:      schema.CodeRepository,
+			TargetProperty:   "Id",
+			TargetNodeId:     secondarySource,
+			RelationshipType: "READS_SOURCE",
+		})
+	}
+
+	for _, secretName := range p.SecretEnvVarNames {
+		relationships = append(relationships, schema.Relationship{
+			SourceLabel:      schema.CodeBuildProject,
+			SourceProperty:   "Id",
+			SourceNodeId:     p.Id,
+			TargetLabel:      schema.Secret,
+			TargetProperty:   "Id",
+			TargetNodeId:     p.Id + ":" + secretName,
+			RelationshipType: "EXPOSES_SECRET",
+		})
+	}
+
+	return relationships
+}
+
+func init() {
+	NodeLabelToNodeMap[schema.CodeBuildProject] = &CodeBuildProject{}
+}
@@ -0,0 +1,84 @@
+package ingestor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	getEnsuredLabelsQuery = `MATCH (m:CloudFoxMeta {key: "schema"}) RETURN m.ensuredLabels AS ensuredLabels`
+	addEnsuredLabelQuery  = `MERGE (m:CloudFoxMeta {key: "schema"})
+	SET m.ensuredLabels = coalesce(m.ensuredLabels, []) + $label, m.updatedAt = datetime()`
+)
+
+// EnsureSchema creates the Id uniqueness constraint and index for every node
+// label the ingestor knows about, via each label's repository. It is
+// idempotent: CREATE CONSTRAINT/INDEX IF NOT EXISTS is safe to repeat, but it
+// isn't free at scale, so a re-run skips any label already recorded on the
+// :CloudFoxMeta node's ensuredLabels list. Tracking the set of ensured
+// labels -- rather than a single hand-maintained version number -- means a
+// label added later is always picked up on the next run, even if whoever
+// added it forgot to bump a version constant.
+func (i *CloudFoxIngestor) EnsureSchema() error {
+	ctx := context.Background()
+
+	if i.repositories == nil {
+		return fmt.Errorf("EnsureSchema called before repositories were initialized")
+	}
+
+	ensured, err := i.ensuredLabels(ctx)
+	if err != nil {
+		return fmt.Errorf("reading previously ensured labels: %w", err)
+	}
+
+	for label, repo := range i.repositories {
+		if ensured[string(label)] {
+			continue
+		}
+
+		log.Infof("Creating constraints and indexes for %s", label)
+		if err := repo.EnsureSchema(ctx); err != nil {
+			log.Errorf("Error ensuring schema for %s: %s", label, err)
+			continue
+		}
+		if err := i.recordEnsuredLabel(ctx, string(label)); err != nil {
+			return fmt.Errorf("recording ensured label %s: %w", label, err)
+		}
+	}
+
+	return nil
+}
+
+func (i *CloudFoxIngestor) ensuredLabels(ctx context.Context) (map[string]bool, error) {
+	result, err := neo4j.ExecuteQuery(ctx, i.Driver, getEnsuredLabelsQuery, nil, neo4j.EagerResultTransformer, neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return nil, err
+	}
+	ensured := make(map[string]bool)
+	if len(result.Records) == 0 {
+		return ensured, nil
+	}
+
+	value, found := result.Records[0].Get("ensuredLabels")
+	if !found || value == nil {
+		return ensured, nil
+	}
+	labels, ok := value.([]interface{})
+	if !ok {
+		return ensured, nil
+	}
+	for _, label := range labels {
+		if s, ok := label.(string); ok {
+			ensured[s] = true
+		}
+	}
+	return ensured, nil
+}
+
+func (i *CloudFoxIngestor) recordEnsuredLabel(ctx context.Context, label string) error {
+	_, err := neo4j.ExecuteQuery(ctx, i.Driver, addEnsuredLabelQuery, map[string]interface{}{"label": label}, neo4j.EagerResultTransformer, neo4j.ExecuteQueryWithDatabase("neo4j"))
+	return err
+}
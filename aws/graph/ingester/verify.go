@@ -0,0 +1,124 @@
+package ingestor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+	log "github.com/sirupsen/logrus"
+)
+
+// VerificationCheck is one assertion run against the graph after ingest, and
+// the count of records that violated it. Passed is true when Count is 0.
+// Advisory is true for checks that are reported but never fail Verify --
+// see verificationChecks below.
+type VerificationCheck struct {
+	Name     string
+	Query    string
+	Count    int64
+	Passed   bool
+	Advisory bool
+}
+
+// verificationChecks are the structural invariants a healthy ingest should
+// satisfy. Each query returns a single "count" column of offending records.
+var verificationChecks = []struct {
+	name     string
+	query    string
+	advisory bool
+}{
+	{
+		name: "orphan nodes",
+		query: `MATCH (n) WHERE NOT n:CloudFoxMeta AND NOT (n)--()
+		RETURN count(n) AS count`,
+	},
+	{
+		name: "dangling relationship endpoints",
+		query: `MATCH (n)-[r]->(m) WHERE n.Id IS NULL OR m.Id IS NULL
+		RETURN count(r) AS count`,
+	},
+	{
+		name: "duplicate Ids that survived merge",
+		query: `MATCH (n) WHERE NOT n:CloudFoxMeta AND n.Id IS NOT NULL
+		WITH n.Id AS id, count(n) AS nodesWithId
+		WHERE nodesWithId > 1
+		RETURN count(id) AS count`,
+	},
+	{
+		// Advisory: this assumes Role ingestion always creates a Role--Account
+		// edge, which isn't something this check can confirm on its own. If
+		// that relationship isn't wired up for some Role source, this would
+		// otherwise flag every Role as a false positive and fail Verify, so
+		// it's reported but never counted against the pass/fail result.
+		name: "roles with no owning account",
+		query: `MATCH (r:Role) WHERE NOT (r)--(:Account)
+		RETURN count(r) AS count`,
+		advisory: true,
+	},
+}
+
+// Verify runs the structural assertion queries in verificationChecks and
+// prints a pass/fail report, analogous to a conversational flow-test harness
+// asserting the shape of a response: it gives the operator a quick signal
+// that the graph is trustworthy before they start querying it. It returns an
+// error if any non-advisory check found offending records.
+func (i *CloudFoxIngestor) Verify() ([]VerificationCheck, error) {
+	ctx := context.Background()
+
+	var results []VerificationCheck
+	var failed []string
+
+	for _, check := range verificationChecks {
+		count, err := i.runVerificationQuery(ctx, check.query)
+		if err != nil {
+			return results, fmt.Errorf("running verification check %q: %w", check.name, err)
+		}
+
+		result := VerificationCheck{Name: check.name, Query: check.query, Count: count, Passed: count == 0, Advisory: check.advisory}
+		results = append(results, result)
+		if !result.Passed && !result.Advisory {
+			failed = append(failed, check.name)
+		}
+	}
+
+	printVerificationReport(results)
+
+	if len(failed) > 0 {
+		return results, fmt.Errorf("graph verification failed: %v", failed)
+	}
+	return results, nil
+}
+
+func (i *CloudFoxIngestor) runVerificationQuery(ctx context.Context, query string) (int64, error) {
+	result, err := neo4j.ExecuteQuery(ctx, i.Driver, query, nil, neo4j.EagerResultTransformer, neo4j.ExecuteQueryWithDatabase("neo4j"))
+	if err != nil {
+		return 0, err
+	}
+	if len(result.Records) == 0 {
+		return 0, nil
+	}
+
+	value, found := result.Records[0].Get("count")
+	if !found {
+		return 0, fmt.Errorf(`query did not return a "count" column`)
+	}
+	count, ok := value.(int64)
+	if !ok {
+		return 0, fmt.Errorf("count column was a %T, not an integer", value)
+	}
+	return count, nil
+}
+
+func printVerificationReport(results []VerificationCheck) {
+	log.Info("Graph verification report:")
+	for _, result := range results {
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+			if result.Advisory {
+				status = "WARN"
+			}
+		}
+		log.Infof("  [%s] %s (%d)", status, result.Name, result.Count)
+	}
+}
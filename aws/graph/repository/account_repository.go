@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/BishopFox/cloudfox/aws/graph/ingester/schema"
+	"github.com/BishopFox/cloudfox/aws/graph/ingester/schema/models"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// AccountRepository reads and writes schema.Account nodes.
+type AccountRepository struct {
+	baseRepository
+}
+
+func NewAccountRepository(driver neo4j.DriverWithContext, database string) *AccountRepository {
+	return &AccountRepository{newBaseRepository(driver, database, schema.Account)}
+}
+
+// FindByID returns the Account with the given Id, or an error if none exists.
+func (r *AccountRepository) FindByID(ctx context.Context, id string) (*models.Account, error) {
+	var account models.Account
+	if err := r.findByID(ctx, id, &account); err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// List returns every Account node currently in the graph.
+func (r *AccountRepository) List(ctx context.Context) ([]models.Account, error) {
+	return list[models.Account](ctx, r.baseRepository)
+}
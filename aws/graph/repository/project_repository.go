@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/BishopFox/cloudfox/aws/graph/ingester/schema"
+	"github.com/BishopFox/cloudfox/aws/graph/ingester/schema/models"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// ProjectRepository reads and writes schema.CodeBuildProject nodes.
+type ProjectRepository struct {
+	baseRepository
+}
+
+func NewProjectRepository(driver neo4j.DriverWithContext, database string) *ProjectRepository {
+	return &ProjectRepository{newBaseRepository(driver, database, schema.CodeBuildProject)}
+}
+
+// FindByID returns the CodeBuildProject with the given Id, or an error if
+// none exists.
+func (r *ProjectRepository) FindByID(ctx context.Context, id string) (*models.CodeBuildProject, error) {
+	var project models.CodeBuildProject
+	if err := r.findByID(ctx, id, &project); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+// List returns every CodeBuildProject node currently in the graph.
+func (r *ProjectRepository) List(ctx context.Context) ([]models.CodeBuildProject, error) {
+	return list[models.CodeBuildProject](ctx, r.baseRepository)
+}
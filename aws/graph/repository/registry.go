@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"github.com/BishopFox/cloudfox/aws/graph/ingester/schema"
+	"github.com/BishopFox/cloudfox/aws/graph/ingester/schema/models"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// genericRepository backs any node label that doesn't yet have a typed
+// repository of its own -- it can still merge nodes/relationships and run
+// EnsureSchema, it just doesn't offer typed FindByID/List helpers.
+type genericRepository struct {
+	baseRepository
+}
+
+// New builds a Repository for every label in models.NodeLabelToNodeMap,
+// using the typed repository where one has been written and falling back to
+// genericRepository otherwise. ProcessFileObjects dispatches onto whatever
+// this map returns, so adding a typed repository for a new node kind doesn't
+// require touching the ingestor.
+func New(driver neo4j.DriverWithContext, database string) map[schema.NodeLabel]Repository {
+	repositories := make(map[schema.NodeLabel]Repository, len(models.NodeLabelToNodeMap))
+	for label := range models.NodeLabelToNodeMap {
+		repositories[label] = genericRepository{newBaseRepository(driver, database, label)}
+	}
+
+	// Typed repositories override the generic fallback for the labels they
+	// know about.
+	repositories[schema.Account] = NewAccountRepository(driver, database)
+	repositories[schema.Role] = NewRoleRepository(driver, database)
+	repositories[schema.CodeBuildProject] = NewProjectRepository(driver, database)
+
+	return repositories
+}
@@ -0,0 +1,188 @@
+// Package repository provides a typed Go API over the graph data that the
+// ingestor writes to Neo4j. Every node kind owns its Cypher, its
+// constraints/indexes, and knows how to hydrate a model back from a
+// neo4j.Record, so downstream tooling (queries, analytics) can read the
+// graph back without hand-writing Cypher.
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/BishopFox/cloudfox/aws/graph/ingester/schema"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+const (
+	// mergeNodeBatchQuery mirrors mergeRelationshipBatchQuery: rows carry
+	// their own labels/properties so a single UNWIND can merge many nodes at
+	// once, regardless of node kind.
+	mergeNodeBatchQuery = `UNWIND $batch as row
+	CALL apoc.merge.node(row.labels, {Id: row.Id}, row.properties, row.properties) YIELD node as obj
+	CALL apoc.create.setLabels(obj, row.labels) YIELD node as labeledObj
+	RETURN labeledObj`
+
+	mergeRelationshipBatchQuery = `UNWIND $batch as row
+	CALL apoc.merge.node([row.sourceLabel], apoc.map.fromValues([row.sourceProperty, row.sourceNodeId])) YIELD node as from
+	CALL apoc.merge.node([row.targetLabel], apoc.map.fromValues([row.targetProperty, row.targetNodeId])) YIELD node as to
+	CALL apoc.merge.relationship(from, row.relationshipType, {}, row.properties, to) YIELD rel
+	RETURN rel`
+
+	createConstraintQuery = "CREATE CONSTRAINT IF NOT EXISTS FOR (n: %s) REQUIRE n.Id IS UNIQUE"
+	createIndexQuery      = "CREATE INDEX %[1]s_Id IF NOT EXISTS FOR (n: %[1]s) ON (n.Id)"
+
+	findByIDQuery = `MATCH (n: %s {Id: $id}) RETURN n LIMIT 1`
+	listQuery     = `MATCH (n: %s) RETURN n`
+)
+
+// Repository is the read/write API a single node kind exposes over the graph.
+// Concrete repositories (AccountRepository, RoleRepository, ...) embed
+// baseRepository and add typed accessors on top of it.
+type Repository interface {
+	Label() schema.NodeLabel
+	EnsureSchema(ctx context.Context) error
+	UpsertNodes(ctx context.Context, rows []map[string]interface{}) error
+	UpsertRelationships(ctx context.Context, rows []map[string]interface{}) error
+}
+
+// baseRepository implements the mechanical parts of Repository -- running
+// batched merges and scanning records back into structs -- so concrete
+// repositories only need to supply their label and typed hydration.
+type baseRepository struct {
+	driver   neo4j.DriverWithContext
+	database string
+	label    schema.NodeLabel
+}
+
+func newBaseRepository(driver neo4j.DriverWithContext, database string, label schema.NodeLabel) baseRepository {
+	return baseRepository{driver: driver, database: database, label: label}
+}
+
+func (r baseRepository) Label() schema.NodeLabel {
+	return r.label
+}
+
+func (r baseRepository) EnsureSchema(ctx context.Context) error {
+	for _, query := range []string{createConstraintQuery, createIndexQuery} {
+		_, err := neo4j.ExecuteQuery(ctx, r.driver, fmt.Sprintf(query, r.label), nil, neo4j.EagerResultTransformer, neo4j.ExecuteQueryWithDatabase(r.database))
+		if err != nil {
+			return fmt.Errorf("ensuring schema for %s: %w", r.label, err)
+		}
+	}
+	return nil
+}
+
+func (r baseRepository) UpsertNodes(ctx context.Context, rows []map[string]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	_, err := neo4j.ExecuteQuery(ctx, r.driver, mergeNodeBatchQuery, map[string]interface{}{"batch": rows}, neo4j.EagerResultTransformer, neo4j.ExecuteQueryWithDatabase(r.database))
+	if err != nil {
+		return fmt.Errorf("upserting %d %s nodes: %w", len(rows), r.label, err)
+	}
+	return nil
+}
+
+func (r baseRepository) UpsertRelationships(ctx context.Context, rows []map[string]interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	_, err := neo4j.ExecuteQuery(ctx, r.driver, mergeRelationshipBatchQuery, map[string]interface{}{"batch": rows}, neo4j.EagerResultTransformer, neo4j.ExecuteQueryWithDatabase(r.database))
+	if err != nil {
+		return fmt.Errorf("upserting %d relationships from %s: %w", len(rows), r.label, err)
+	}
+	return nil
+}
+
+// findByID runs findByIDQuery for the repository's label and scans the sole
+// "n" column into target.
+func (r baseRepository) findByID(ctx context.Context, id string, target interface{}) error {
+	result, err := neo4j.ExecuteQuery(ctx, r.driver, fmt.Sprintf(findByIDQuery, r.label), map[string]interface{}{"id": id}, neo4j.EagerResultTransformer, neo4j.ExecuteQueryWithDatabase(r.database))
+	if err != nil {
+		return fmt.Errorf("finding %s %s: %w", r.label, id, err)
+	}
+	if len(result.Records) == 0 {
+		return fmt.Errorf("no %s found with Id %s", r.label, id)
+	}
+	return ScanIntoStruct(result.Records[0], "n", target)
+}
+
+// list runs listQuery for the repository's label and hydrates every record
+// into a fresh T via ScanIntoStruct.
+func list[T any](ctx context.Context, r baseRepository) ([]T, error) {
+	result, err := neo4j.ExecuteQuery(ctx, r.driver, fmt.Sprintf(listQuery, r.label), nil, neo4j.EagerResultTransformer, neo4j.ExecuteQueryWithDatabase(r.database))
+	if err != nil {
+		return nil, fmt.Errorf("listing %s nodes: %w", r.label, err)
+	}
+	return scan[T](result.Records, "n")
+}
+
+// scan hydrates every record's key column into a T. It stops at the first
+// record that fails to convert and returns the items scanned so far along
+// with that error.
+func scan[T any](records []*neo4j.Record, key string) ([]T, error) {
+	out := make([]T, 0, len(records))
+	for _, record := range records {
+		var item T
+		if err := ScanIntoStruct(record, key, &item); err != nil {
+			return out, err
+		}
+		out = append(out, item)
+	}
+	return out, nil
+}
+
+// ScanIntoStruct hydrates the neo4j.Node (or map) stored under key in record
+// into target by round-tripping through JSON, mirroring how
+// schema.ConvertCustomTypesToNeo4j flattens a model going the other way.
+func ScanIntoStruct(record *neo4j.Record, key string, target interface{}) error {
+	raw, found := record.Get(key)
+	if !found {
+		return fmt.Errorf("column %q not present in record", key)
+	}
+
+	var props map[string]interface{}
+	switch v := raw.(type) {
+	case neo4j.Node:
+		props = v.Props
+	case map[string]interface{}:
+		props = v
+	default:
+		return fmt.Errorf("column %q is a %T, not a node or map", key, raw)
+	}
+
+	encoded, err := json.Marshal(props)
+	if err != nil {
+		return fmt.Errorf("marshaling %q for scan: %w", key, err)
+	}
+	if err := json.Unmarshal(encoded, target); err != nil {
+		return fmt.Errorf("scanning %q into %T: %w", key, target, err)
+	}
+	return nil
+}
+
+// ParseIDsFromRecord reads a list-valued column (e.g. the collected Ids from
+// an UNWIND or COLLECT) into a []string, which is the shape most relationship
+// endpoint queries return.
+func ParseIDsFromRecord(record *neo4j.Record, key string) ([]string, error) {
+	raw, found := record.Get(key)
+	if !found {
+		return nil, fmt.Errorf("column %q not present in record", key)
+	}
+
+	values, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("column %q is a %T, not a list", key, raw)
+	}
+
+	ids := make([]string, 0, len(values))
+	for _, value := range values {
+		id, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("column %q contains a non-string Id: %v", key, value)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/BishopFox/cloudfox/aws/graph/ingester/schema"
+	"github.com/BishopFox/cloudfox/aws/graph/ingester/schema/models"
+	"github.com/neo4j/neo4j-go-driver/v5/neo4j"
+)
+
+// RoleRepository reads and writes schema.Role nodes.
+type RoleRepository struct {
+	baseRepository
+}
+
+func NewRoleRepository(driver neo4j.DriverWithContext, database string) *RoleRepository {
+	return &RoleRepository{newBaseRepository(driver, database, schema.Role)}
+}
+
+// FindByID returns the Role with the given Id, or an error if none exists.
+func (r *RoleRepository) FindByID(ctx context.Context, id string) (*models.Role, error) {
+	var role models.Role
+	if err := r.findByID(ctx, id, &role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// List returns every Role node currently in the graph.
+func (r *RoleRepository) List(ctx context.Context) ([]models.Role, error) {
+	return list[models.Role](ctx, r.baseRepository)
+}